@@ -0,0 +1,86 @@
+package forward
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// defaultBootstrapInterval is how often a bootstrapped proxy's hostname is re-resolved when no
+// explicit bootstrap_interval is configured.
+const defaultBootstrapInterval = 5 * time.Minute
+
+// bootstrap periodically resolves proxy hostnames into concrete addresses using a fixed set of
+// IP-based resolvers, so long-lived upstreams configured by name (e.g. dns.google) can be dialed
+// without requiring those names to be resolvable through the forwarding loop itself.
+type bootstrap struct {
+	resolvers []string
+	interval  time.Duration
+	client    *dns.Client
+}
+
+func newBootstrap(resolvers []string, interval time.Duration) *bootstrap {
+	if interval == 0 {
+		interval = defaultBootstrapInterval
+	}
+	return &bootstrap{
+		resolvers: resolvers,
+		interval:  interval,
+		client:    &dns.Client{Net: "udp", ReadTimeout: 2 * time.Second, WriteTimeout: 2 * time.Second},
+	}
+}
+
+// resolve looks up host's A record against the configured resolvers, in order, returning the
+// first address found.
+func (b *bootstrap) resolve(host string) (string, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(host), dns.TypeA)
+
+	var lastErr error
+	for _, r := range b.resolvers {
+		in, _, err := b.client.Exchange(m, r)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, rr := range in.Answer {
+			if a, ok := rr.(*dns.A); ok {
+				return a.A.String(), nil
+			}
+		}
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("bootstrap: resolving %s: %s", host, lastErr)
+	}
+	return "", fmt.Errorf("bootstrap: no address found for %s", host)
+}
+
+// start resolves p's hostname immediately, then keeps re-resolving it on b.interval until p is
+// stopped.
+func (b *bootstrap) start(p *Proxy) {
+	b.refresh(p)
+
+	go func() {
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stopCh:
+				return
+			case <-ticker.C:
+				b.refresh(p)
+			}
+		}
+	}()
+}
+
+// refresh re-resolves p's hostname and, on success, atomically swaps the address p dials.
+func (b *bootstrap) refresh(p *Proxy) {
+	ip, err := b.resolve(p.hostname)
+	if err != nil {
+		return
+	}
+	p.setDialAddr(net.JoinHostPort(ip, p.port))
+}
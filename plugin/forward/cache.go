@@ -0,0 +1,198 @@
+package forward
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// defaultCacheCapacity is the number of entries a cache block keeps when no explicit capacity is
+// given.
+const defaultCacheCapacity = 10000
+
+// Default bounds applied to the TTL a cached answer is stored with, per RFC 2308 section 3 for negatives.
+const (
+	defaultMinTTL      = 5 * time.Second
+	defaultMaxTTL      = 1 * time.Hour
+	defaultNegativeTTL = 5 * time.Minute
+)
+
+// cacheKey identifies a cached answer. Responses differ based on whether DNSSEC records were
+// requested, so the DO bit is part of the key.
+type cacheKey struct {
+	qname  string
+	qtype  uint16
+	qclass uint16
+	do     bool
+}
+
+func cacheKeyFor(r *dns.Msg) cacheKey {
+	q := r.Question[0]
+	return cacheKey{qname: q.Name, qtype: q.Qtype, qclass: q.Qclass, do: isDNSSEC(r)}
+}
+
+func isDNSSEC(r *dns.Msg) bool {
+	if o := r.IsEdns0(); o != nil {
+		return o.Do()
+	}
+	return false
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	msg      *dns.Msg
+	cachedAt time.Time
+	expires  time.Time
+	elem     *list.Element
+}
+
+// forwardCache is a small LRU-backed cache of upstream answers, keyed by qname/qtype/qclass/DO,
+// honoring per-RR TTLs (clamped to [minTTL, maxTTL]) and a distinct TTL for negative answers.
+type forwardCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[cacheKey]*cacheEntry
+	order    *list.List // front = most recently used
+
+	minTTL time.Duration
+	maxTTL time.Duration
+	negTTL time.Duration
+}
+
+func newForwardCache() *forwardCache {
+	return &forwardCache{
+		capacity: defaultCacheCapacity,
+		entries:  make(map[cacheKey]*cacheEntry),
+		order:    list.New(),
+		minTTL:   defaultMinTTL,
+		maxTTL:   defaultMaxTTL,
+		negTTL:   defaultNegativeTTL,
+	}
+}
+
+// get returns a copy of the cached response for r, with RR TTLs decremented by the time spent
+// in the cache, or nil if there is no live entry.
+func (c *forwardCache) get(r *dns.Msg) *dns.Msg {
+	key := cacheKeyFor(r)
+
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.mu.Unlock()
+		CacheMissCount.WithLabelValues().Add(1)
+		return nil
+	}
+	if !now.Before(e.expires) {
+		c.removeLocked(e)
+		c.mu.Unlock()
+		CacheMissCount.WithLabelValues().Add(1)
+		return nil
+	}
+	c.order.MoveToFront(e.elem)
+	msg := e.msg.Copy()
+	elapsed := uint32(now.Sub(e.cachedAt).Seconds())
+	c.mu.Unlock()
+
+	CacheHitCount.WithLabelValues().Add(1)
+	decrementTTL(msg, elapsed)
+	msg.Id = r.Id
+	return msg
+}
+
+// set stores ret (the upstream's answer to r) in the cache, with a TTL derived from its RRs and
+// clamped to [minTTL, maxTTL], or negTTL for NXDOMAIN/NODATA responses.
+func (c *forwardCache) set(r, ret *dns.Msg) {
+	if len(r.Question) == 0 || !isCacheable(ret) {
+		return
+	}
+	key := cacheKeyFor(r)
+	ttl := c.ttlFor(ret)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.removeLocked(e)
+	}
+
+	now := time.Now()
+	e := &cacheEntry{key: key, msg: ret.Copy(), cachedAt: now, expires: now.Add(ttl)}
+	e.elem = c.order.PushFront(e)
+	c.entries[key] = e
+
+	for c.order.Len() > c.capacity {
+		c.removeLocked(c.order.Back().Value.(*cacheEntry))
+	}
+}
+
+func (c *forwardCache) removeLocked(e *cacheEntry) {
+	c.order.Remove(e.elem)
+	delete(c.entries, e.key)
+}
+
+// ttlFor computes the TTL a response should be cached for: the minimum RR TTL for a positive
+// answer, clamped to [minTTL, maxTTL], or negTTL, unclamped, for NXDOMAIN/NODATA per RFC 2308.
+func (c *forwardCache) ttlFor(m *dns.Msg) time.Duration {
+	if isNegative(m) {
+		return c.negTTL
+	}
+
+	min := uint32(0)
+	found := false
+	for _, rr := range append(append(append([]dns.RR{}, m.Answer...), m.Ns...), m.Extra...) {
+		if _, ok := rr.(*dns.OPT); ok {
+			continue
+		}
+		if !found || rr.Header().Ttl < min {
+			min = rr.Header().Ttl
+			found = true
+		}
+	}
+	ttl := time.Duration(min) * time.Second
+	if ttl < c.minTTL {
+		ttl = c.minTTL
+	}
+	if ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+	return ttl
+}
+
+func isNegative(m *dns.Msg) bool {
+	return m.Rcode == dns.RcodeNameError || (m.Rcode == dns.RcodeSuccess && len(m.Answer) == 0)
+}
+
+// isCacheable reports whether m is safe to serve from the cache on a later, unrelated query.
+func isCacheable(m *dns.Msg) bool {
+	return m.Rcode == dns.RcodeSuccess || m.Rcode == dns.RcodeNameError
+}
+
+func decrementTTL(m *dns.Msg, age uint32) {
+	for _, rrs := range [][]dns.RR{m.Answer, m.Ns, m.Extra} {
+		for _, rr := range rrs {
+			if rr.Header().Ttl > age {
+				rr.Header().Ttl -= age
+			} else {
+				rr.Header().Ttl = 0
+			}
+		}
+	}
+}
+
+// parseCacheSize parses the optional capacity argument of the cache block.
+func parseCacheSize(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("cache capacity must be positive: %d", n)
+	}
+	return n, nil
+}
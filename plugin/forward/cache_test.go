@@ -0,0 +1,95 @@
+package forward
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func testQuery(qname string) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(qname), dns.TypeA)
+	return m
+}
+
+func TestCacheGetSetRoundTrip(t *testing.T) {
+	c := newForwardCache()
+
+	q := testQuery("example.org.")
+	ret := new(dns.Msg)
+	ret.SetReply(q)
+	rr, err := dns.NewRR("example.org. 100 IN A 1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret.Answer = []dns.RR{rr}
+
+	c.set(q, ret)
+
+	cached := c.get(q)
+	if cached == nil {
+		t.Fatal("expected a cache hit immediately after set")
+	}
+	if got := cached.Answer[0].Header().Ttl; got != 100 {
+		t.Errorf("TTL right after caching: got %d, want ~100 (elapsed time should be ~0)", got)
+	}
+}
+
+func TestCacheGetDecrementsByElapsedTime(t *testing.T) {
+	c := newForwardCache()
+
+	q := testQuery("example.org.")
+	ret := new(dns.Msg)
+	ret.SetReply(q)
+	rr, err := dns.NewRR("example.org. 100 IN A 1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret.Answer = []dns.RR{rr}
+	c.set(q, ret)
+
+	// Backdate cachedAt to simulate time having passed, without sleeping in the test.
+	key := cacheKeyFor(q)
+	e := c.entries[key]
+	e.cachedAt = e.cachedAt.Add(-40 * time.Second)
+
+	cached := c.get(q)
+	if cached == nil {
+		t.Fatal("expected a cache hit for an entry that has not expired")
+	}
+	if got := cached.Answer[0].Header().Ttl; got != 60 {
+		t.Errorf("TTL after 40s elapsed: got %d, want 60 (100 - 40)", got)
+	}
+}
+
+func TestCacheGetExpired(t *testing.T) {
+	c := newForwardCache()
+	c.negTTL = time.Millisecond
+
+	q := testQuery("nx.example.org.")
+	ret := new(dns.Msg)
+	ret.SetReply(q)
+	ret.Rcode = dns.RcodeNameError
+	c.set(q, ret)
+
+	time.Sleep(5 * time.Millisecond)
+
+	if cached := c.get(q); cached != nil {
+		t.Fatal("expected a cache miss for an expired entry")
+	}
+}
+
+func TestCacheNegativeTTL(t *testing.T) {
+	c := newForwardCache()
+	c.negTTL = 30 * time.Second
+
+	q := testQuery("nx.example.org.")
+	ret := new(dns.Msg)
+	ret.SetReply(q)
+	ret.Rcode = dns.RcodeNameError
+
+	if got := c.ttlFor(ret); got != c.negTTL {
+		t.Errorf("ttlFor(NXDOMAIN) = %s, want %s", got, c.negTTL)
+	}
+}
@@ -0,0 +1,98 @@
+package forward
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	tap "github.com/dnstap/golang-dnstap"
+	"github.com/miekg/dns"
+)
+
+// tapQuery emits a FORWARDER_QUERY dnstap message for a query about to be sent to proxy.
+func (f *Forward) tapQuery(proxy *Proxy, query *dns.Msg, queryTime time.Time) {
+	if f.tapPlugin == nil {
+		return
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return
+	}
+
+	sec, nsec := split(queryTime)
+	f.tapPlugin.TapMessage(&tap.Message{
+		Type:            tap.Message_FORWARDER_QUERY.Enum(),
+		SocketProtocol:  socketProtocol(proxy.transport),
+		ResponseAddress: addrBytes(proxy.dial()),
+		ResponsePort:    portNum(proxy.dial()),
+		QueryTimeSec:    &sec,
+		QueryTimeNsec:   &nsec,
+		QueryMessage:    packed,
+	})
+}
+
+// tapResponse emits a FORWARDER_RESPONSE dnstap message for the reply (if any) received from
+// proxy, recording truncation/error status via the presence of a response message.
+func (f *Forward) tapResponse(proxy *Proxy, response *dns.Msg, respTime time.Time) {
+	if f.tapPlugin == nil {
+		return
+	}
+
+	sec, nsec := split(respTime)
+	m := &tap.Message{
+		Type:             tap.Message_FORWARDER_RESPONSE.Enum(),
+		SocketProtocol:   socketProtocol(proxy.transport),
+		ResponseAddress:  addrBytes(proxy.dial()),
+		ResponsePort:     portNum(proxy.dial()),
+		ResponseTimeSec:  &sec,
+		ResponseTimeNsec: &nsec,
+	}
+	if response != nil {
+		if packed, err := response.Pack(); err == nil {
+			m.ResponseMessage = packed
+		}
+	}
+	f.tapPlugin.TapMessage(m)
+}
+
+func split(t time.Time) (sec uint64, nsec uint32) { return uint64(t.Unix()), uint32(t.Nanosecond()) }
+
+// socketProtocol maps our transport name onto the dnstap wire-level protocol. DoT and DoH both
+// ride over a TCP socket, so both are reported as such.
+func socketProtocol(trans string) *tap.SocketProtocol {
+	p := tap.SocketProtocol_UDP
+	if trans == transport.TLS || trans == transport.HTTPS {
+		p = tap.SocketProtocol_TCP
+	}
+	return &p
+}
+
+func addrBytes(addr string) []byte {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+func portNum(addr string) *uint32 {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return nil
+	}
+	p := uint32(n)
+	return &p
+}
@@ -0,0 +1,52 @@
+package forward
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	tap "github.com/dnstap/golang-dnstap"
+	"github.com/miekg/dns"
+)
+
+func TestSocketProtocol(t *testing.T) {
+	tests := []struct {
+		trans string
+		want  tap.SocketProtocol
+	}{
+		{transport.DNS, tap.SocketProtocol_UDP},
+		{transport.TLS, tap.SocketProtocol_TCP},
+		{transport.HTTPS, tap.SocketProtocol_TCP},
+	}
+	for _, tc := range tests {
+		if got := *socketProtocol(tc.trans); got != tc.want {
+			t.Errorf("socketProtocol(%q) = %v, want %v", tc.trans, got, tc.want)
+		}
+	}
+}
+
+func TestAddrBytesAndPortNum(t *testing.T) {
+	if got := net.IP(addrBytes("127.0.0.1:53")).String(); got != "127.0.0.1" {
+		t.Errorf("addrBytes(%q) = %v, want 127.0.0.1", "127.0.0.1:53", got)
+	}
+	if got := portNum("127.0.0.1:53"); got == nil || *got != 53 {
+		t.Errorf("portNum(%q) = %v, want 53", "127.0.0.1:53", got)
+	}
+	if got := addrBytes("not-an-addr"); got != nil {
+		t.Errorf("addrBytes(%q) = %v, want nil", "not-an-addr", got)
+	}
+}
+
+// TestTapNoOpWithoutPlugin verifies tapQuery/tapResponse are safe no-ops when no dnstap plugin is
+// wired up, including when the upstream returned no response (e.g. on error).
+func TestTapNoOpWithoutPlugin(t *testing.T) {
+	f := New()
+	p := NewProxy("127.0.0.1:53", transport.DNS)
+
+	q := new(dns.Msg)
+	q.SetQuestion("example.org.", dns.TypeA)
+
+	f.tapQuery(p, q, time.Now())
+	f.tapResponse(p, nil, time.Now())
+}
@@ -0,0 +1,127 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// dohPath is the well-known endpoint DoH upstreams serve wire-format queries on, per RFC 8484.
+const dohPath = "/dns-query"
+
+// dohMimeType is the media type for the DNS wire format carried in DoH bodies.
+const dohMimeType = "application/dns-message"
+
+// dohClient issues DNS-over-HTTPS queries against a single upstream, reusing a pooled
+// *http.Transport so connections (and their TLS sessions) are kept alive across queries.
+//
+// transport is held behind an atomic.Value rather than mutated in place: bootstrap re-resolution
+// and healthcheck failures call setDialAddr from a background goroutine while exchange may have
+// in-flight requests reading the transport concurrently. setTLSConfig/setDialAddr build a new
+// *http.Transport and swap it in, so a reader always sees a fully-formed transport. mu serializes
+// the two setters against each other so a concurrent TLS-config change and dial-addr change can't
+// race to overwrite one another.
+type dohClient struct {
+	addr string
+	url  string
+
+	mu        sync.Mutex
+	transport atomic.Value // *http.Transport
+}
+
+func newDOHClient(addr string) *dohClient {
+	d := &dohClient{
+		addr: addr,
+		url:  "https://" + addr + dohPath,
+	}
+	d.transport.Store(&http.Transport{
+		MaxIdleConns:        200,
+		MaxIdleConnsPerHost: 200,
+		IdleConnTimeout:     defaultExpire,
+	})
+	return d
+}
+
+func (d *dohClient) current() *http.Transport { return d.transport.Load().(*http.Transport) }
+
+// cloneTransport copies the fields dohClient sets onto a fresh *http.Transport. go.mod pins go
+// 1.12, before http.Transport gained Clone(), so this copies them by hand.
+func cloneTransport(t *http.Transport) *http.Transport {
+	return &http.Transport{
+		MaxIdleConns:        t.MaxIdleConns,
+		MaxIdleConnsPerHost: t.MaxIdleConnsPerHost,
+		IdleConnTimeout:     t.IdleConnTimeout,
+		TLSClientConfig:     t.TLSClientConfig,
+		DialContext:         t.DialContext,
+	}
+}
+
+func (d *dohClient) setTLSConfig(cfg *tls.Config) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t := cloneTransport(d.current())
+	t.TLSClientConfig = cfg
+	d.transport.Store(t)
+}
+
+// setDialAddr pins the TCP address dialed for d.url to addr, leaving TLS verification (which
+// uses d.url's hostname) untouched. bootstrap uses this to re-point a DoH upstream at a freshly
+// resolved IP without disturbing certificate validation.
+func (d *dohClient) setDialAddr(addr string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t := cloneTransport(d.current())
+	t.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, addr)
+	}
+	d.transport.Store(t)
+}
+
+// exchange POSTs the wire-format query to the upstream's /dns-query endpoint and parses the
+// wire-format response, per RFC 8484 section 4.1.
+func (d *dohClient) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", dohMimeType)
+	req.Header.Set("Accept", dohMimeType)
+
+	client := &http.Client{Transport: d.current(), Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh: upstream %s returned status %d", d.addr, resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := new(dns.Msg)
+	if err := ret.Unpack(body); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
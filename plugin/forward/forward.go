@@ -0,0 +1,207 @@
+// Package forward implements a forwarding proxy. DoH upstreams reuse pooled HTTP connections across
+// queries; plain DNS and DoT upstreams are dialed anew for every query, so no upstream net.Conn is
+// cached between queries.
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/dnstap"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Forward represents a plugin instance that can do a insecure forward query.
+type Forward struct {
+	proxies []*Proxy
+	p       policy
+
+	from    string
+	ignored []string
+
+	route *routeTable
+	cache *forwardCache
+
+	bootstrapResolvers []string
+	bootstrapInterval  time.Duration
+
+	dnstapEnabled bool
+	tapPlugin     dnstap.Dnstapper
+
+	tlsConfig     *tls.Config
+	tlsServerName string
+	maxfails      uint32
+	expire        time.Duration
+
+	opts options // also here for testing
+
+	hcInterval time.Duration
+
+	// ErrLimitExceeded indicates that a query was rejected because the
+	// number of concurrent queries exceeded the maximum allowed (maxConcurrent)
+	ErrLimitExceeded error
+
+	maxConcurrent int64
+	concurrent    int64
+
+	// Failed queries, along with the previous metadata, can be retried again using different upstream.
+	maxFailedTries int
+
+	Next plugin.Handler
+
+	index int
+}
+
+// options holds various Options that can be set.
+type options struct {
+	forceTCP           bool
+	preferUDP          bool
+	hcRecursionDesired bool
+}
+
+// New returns a new Forward.
+func New() *Forward {
+	f := &Forward{maxfails: 2, tlsConfig: new(tls.Config), expire: defaultExpire, p: new(random), from: ".", hcInterval: hcDuration, opts: options{hcRecursionDesired: true}}
+	return f
+}
+
+// Name implements plugin.Handler.
+func (f *Forward) Name() string { return "forward" }
+
+// Len returns the number of configured remote upstreams.
+func (f *Forward) Len() int { return len(f.proxies) }
+
+// ServeDNS implements plugin.Handler.
+func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if !f.match(state) {
+		return plugin.NextOrFailure(f.Name(), f.Next, ctx, w, r)
+	}
+
+	if f.cache != nil {
+		if cached := f.cache.get(r); cached != nil {
+			w.WriteMsg(cached)
+			return 0, nil
+		}
+	}
+
+	if f.maxConcurrent > 0 {
+		if atomic.AddInt64(&f.concurrent, 1) > f.maxConcurrent {
+			atomic.AddInt64(&f.concurrent, -1)
+			MaxConcurrentRejectCount.WithLabelValues().Add(1)
+			return dns.RcodeServerFailure, f.ErrLimitExceeded
+		}
+		defer atomic.AddInt64(&f.concurrent, -1)
+	}
+
+	fails := 0
+	var (
+		ret *dns.Msg
+		err error
+	)
+	proxies := f.list(state.Name())
+	for _, proxy := range proxies {
+		if proxy.Down(f.maxfails) {
+			fails++
+			if fails < len(proxies) {
+				continue
+			}
+			// All upstream proxies are dead, assume healthcheck is completely broken and connect
+			// to the last upstream in the policy's order anyway.
+		}
+
+		queryTime := time.Now()
+		f.tapQuery(proxy, r, queryTime)
+
+		ret, err = proxy.Connect(ctx, state, f.opts)
+
+		f.tapResponse(proxy, ret, time.Now())
+
+		if err != nil && fails < f.maxFailedTries && shouldRetry(err) {
+			continue
+		}
+		if err != nil {
+			break
+		}
+
+		if f.cache != nil {
+			f.cache.set(r, ret)
+		}
+
+		w.WriteMsg(ret)
+		return 0, nil
+	}
+
+	if err != nil {
+		return dns.RcodeServerFailure, err
+	}
+
+	return dns.RcodeServerFailure, ErrNoHealthy
+}
+
+func (f *Forward) match(state request.Request) bool {
+	if !plugin.Name(f.from).Matches(state.Name()) || !f.isAllowedDomain(state.Name()) {
+		return false
+	}
+
+	return true
+}
+
+func (f *Forward) isAllowedDomain(name string) bool {
+	if dns.Name(name) == dns.Name(f.from) {
+		return true
+	}
+
+	for _, ignore := range f.ignored {
+		if plugin.Name(ignore).Matches(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// list returns the proxies eligible to answer qname, in the order the load-balancing policy
+// picks them. If a routing table is configured and a rule matches qname, only the proxies named
+// by that rule are considered.
+func (f *Forward) list(qname string) []*Proxy {
+	proxies := f.proxies
+	if f.route != nil {
+		if addrs, ok := f.route.route(qname); ok {
+			proxies = f.filterProxies(addrs)
+		}
+	}
+	return f.p.List(proxies)
+}
+
+// filterProxies returns the subset of f.proxies whose address is in addrs, preserving the
+// configured order. Route rule addresses are validated against f.proxies at parse time, and
+// route_file rules again on every reload, so in practice this always finds at least one match.
+func (f *Forward) filterProxies(addrs []string) []*Proxy {
+	set := make(map[string]bool, len(addrs))
+	for _, a := range addrs {
+		set[a] = true
+	}
+	out := make([]*Proxy, 0, len(addrs))
+	for _, p := range f.proxies {
+		if set[p.addr] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func shouldRetry(err error) bool { return err != nil }
+
+// ErrNoHealthy is returned when no healthy proxies are left.
+var ErrNoHealthy = errors.New("no healthy proxies")
+
+const (
+	defaultExpire = 10 * time.Second
+	hcDuration    = 500 * time.Millisecond
+)
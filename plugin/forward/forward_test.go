@@ -0,0 +1,40 @@
+package forward
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+)
+
+type testResponseWriter struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+func (w *testResponseWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+
+func TestServeDNSRejectsOverMaxConcurrent(t *testing.T) {
+	f := New()
+	f.proxies = []*Proxy{NewProxy("127.0.0.1:53", transport.DNS)}
+	f.maxConcurrent = 1
+	f.ErrLimitExceeded = errors.New("concurrent queries exceeded maximum 1")
+	f.concurrent = 1 // simulate one query already in flight
+
+	r := new(dns.Msg)
+	r.SetQuestion("example.org.", dns.TypeA)
+
+	rcode, err := f.ServeDNS(context.Background(), &testResponseWriter{}, r)
+	if rcode != dns.RcodeServerFailure || err != f.ErrLimitExceeded {
+		t.Fatalf("ServeDNS over limit = (%d, %v), want (%d, %v)", rcode, err, dns.RcodeServerFailure, f.ErrLimitExceeded)
+	}
+	if f.concurrent != 1 {
+		t.Errorf("concurrent = %d after rejection, want 1 (unchanged)", f.concurrent)
+	}
+}
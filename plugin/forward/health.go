@@ -0,0 +1,45 @@
+package forward
+
+import (
+	"context"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// HealthChecker checks the health of a proxy by sending it a query periodically.
+type HealthChecker struct {
+	client           *dns.Client
+	recursionDesired bool
+}
+
+func newHealthChecker(trans string) *HealthChecker {
+	c := new(dns.Client)
+	c.Net = netForTransport(trans)
+	c.ReadTimeout = 1 * time.Second
+	c.WriteTimeout = 1 * time.Second
+	return &HealthChecker{client: c, recursionDesired: true}
+}
+
+// SetRecursionDesired sets whether the RD bit is set on the healthcheck query.
+func (h *HealthChecker) SetRecursionDesired(recursionDesired bool) {
+	h.recursionDesired = recursionDesired
+}
+
+// Check sends a healthcheck query to the proxy and reports an error on failure.
+func (h *HealthChecker) Check(p *Proxy) error {
+	hcQuery := new(dns.Msg)
+	hcQuery.SetQuestion(".", dns.TypeNS)
+	hcQuery.RecursionDesired = h.recursionDesired
+
+	var err error
+	if p.doh != nil {
+		_, err = p.doh.exchange(context.Background(), hcQuery)
+	} else {
+		_, _, err = h.client.Exchange(hcQuery, p.dial())
+	}
+	if err != nil {
+		HealthcheckFailureCount.WithLabelValues(p.addr).Add(1)
+	}
+	return err
+}
@@ -0,0 +1,34 @@
+package forward
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+func TestNewHealthCheckerUsesTransportNet(t *testing.T) {
+	tests := []struct {
+		trans string
+		want  string
+	}{
+		{transport.DNS, "udp"},
+		{transport.TLS, "tcp-tls"},
+	}
+	for _, tc := range tests {
+		h := newHealthChecker(tc.trans)
+		if h.client.Net != tc.want {
+			t.Errorf("newHealthChecker(%q).client.Net = %q, want %q", tc.trans, h.client.Net, tc.want)
+		}
+	}
+}
+
+func TestSetTLSConfigPropagatesToHealthClient(t *testing.T) {
+	p := NewProxy("127.0.0.1:853", transport.TLS)
+	cfg := &tls.Config{ServerName: "example.org"}
+	p.SetTLSConfig(cfg)
+
+	if p.health.client.TLSConfig != cfg {
+		t.Fatal("SetTLSConfig did not propagate the TLS config to the healthcheck client")
+	}
+}
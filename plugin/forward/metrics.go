@@ -0,0 +1,78 @@
+package forward
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Variables declared for monitoring.
+var (
+	RequestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "request_count_total",
+		Help:      "Counter of requests made per upstream.",
+	}, []string{"to"})
+
+	RcodeCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "response_rcode_count_total",
+		Help:      "Counter of responses received, per upstream and rcode.",
+	}, []string{"rcode", "to"})
+
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "request_duration_seconds",
+		Buckets:   prometheus.ExponentialBuckets(0.00025, 2, 16),
+		Help:      "Histogram of the time each request took, per upstream.",
+	}, []string{"to"})
+
+	HealthcheckFailureCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "healthcheck_failure_count_total",
+		Help:      "Counter of the number of failed healthchecks, per upstream.",
+	}, []string{"to"})
+
+	MaxConcurrentRejectCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "max_concurrent_rejects_total",
+		Help:      "Counter of the number of queries rejected because of concurrency limit.",
+	}, []string{})
+
+	// RouteMatchCount counts, per routing rule pattern, how many queries it matched.
+	RouteMatchCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "route_match_count_total",
+		Help:      "Counter of queries matched per routing rule.",
+	}, []string{"rule"})
+
+	// LatencyEWMA reports, per upstream, the EWMA response time (in seconds) used by the
+	// latency policy to pick the fastest healthy upstream.
+	LatencyEWMA = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "latency_ewma_seconds",
+		Help:      "EWMA of response time, in seconds, per upstream.",
+	}, []string{"to"})
+
+	// CacheHitCount and CacheMissCount count lookups against the in-plugin response cache.
+	CacheHitCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "cache_hits_total",
+		Help:      "Counter of cache hits in the forward response cache.",
+	}, []string{})
+
+	CacheMissCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "cache_misses_total",
+		Help:      "Counter of cache misses in the forward response cache.",
+	}, []string{})
+)
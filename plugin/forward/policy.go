@@ -0,0 +1,121 @@
+package forward
+
+import (
+	"math/rand"
+	"sort"
+	"sync/atomic"
+)
+
+// policy defines a policy we use for selecting upstreams.
+type policy interface {
+	List([]*Proxy) []*Proxy
+}
+
+// random is a policy that randomizes the order in which upstreams are used.
+type random struct{}
+
+func (r *random) List(p []*Proxy) []*Proxy {
+	switch len(p) {
+	case 1:
+		return p
+	case 2:
+		if rand.Int()%2 == 0 {
+			return []*Proxy{p[1], p[0]}
+		}
+		return p
+	}
+
+	perms := rand.Perm(len(p))
+	rnd := make([]*Proxy, len(p))
+	for i, p1 := range perms {
+		rnd[i] = p[p1]
+	}
+	return rnd
+}
+
+// roundRobin is a policy that selects hosts based on a round robin scheme.
+type roundRobin struct {
+	robin uint32
+}
+
+func (r *roundRobin) List(p []*Proxy) []*Proxy {
+	poolLen := uint32(len(p))
+	if poolLen == 0 {
+		return p
+	}
+	i := atomic.AddUint32(&r.robin, 1) % poolLen
+
+	robin := []*Proxy{p[i]}
+	robin = append(robin, p[:i]...)
+	robin = append(robin, p[i+1:]...)
+
+	return robin
+}
+
+// sequential is a policy that selects hosts in the order they are defined.
+type sequential struct{}
+
+func (r *sequential) List(p []*Proxy) []*Proxy { return p }
+
+// weighted is a policy that draws upstreams with probability proportional to their configured
+// weight (see Proxy.weight, set from a "#<weight>" suffix on a to-address).
+type weighted struct{}
+
+func (w *weighted) List(p []*Proxy) []*Proxy {
+	if len(p) <= 1 {
+		return p
+	}
+
+	total := 0
+	for _, proxy := range p {
+		total += proxy.weight
+	}
+	if total <= 0 {
+		return p
+	}
+
+	remaining := make([]*Proxy, len(p))
+	copy(remaining, p)
+	out := make([]*Proxy, 0, len(p))
+
+	for len(remaining) > 0 {
+		sum := 0
+		for _, proxy := range remaining {
+			sum += proxy.weight
+		}
+		draw := rand.Intn(sum)
+		cum := 0
+		for i, proxy := range remaining {
+			cum += proxy.weight
+			if draw < cum {
+				out = append(out, proxy)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// latencyEpsilon is the fraction of selections latency spends exploring a random upstream
+// instead of the currently-fastest one, so a newly-healthy but untested upstream isn't starved.
+const latencyEpsilon = 0.1
+
+// latency is a policy that prefers the upstream with the lowest EWMA response time, with a
+// small random exploration factor.
+type latency struct{}
+
+func (l *latency) List(p []*Proxy) []*Proxy {
+	if len(p) <= 1 {
+		return p
+	}
+
+	if rand.Float64() < latencyEpsilon {
+		return (&random{}).List(p)
+	}
+
+	out := make([]*Proxy, len(p))
+	copy(out, p)
+	sort.Slice(out, func(i, j int) bool { return out[i].latencyEWMA() < out[j].latencyEWMA() })
+	return out
+}
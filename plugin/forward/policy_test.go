@@ -0,0 +1,82 @@
+package forward
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRoundRobinListEmptyDoesNotPanic guards against a regression: roundRobin.List used to divide
+// by len(p), which panics on an empty proxy list (e.g. a route rule left with no valid upstreams).
+func TestRoundRobinListEmptyDoesNotPanic(t *testing.T) {
+	if out := (&roundRobin{}).List(nil); len(out) != 0 {
+		t.Errorf("roundRobin.List(nil) = %v, want empty", out)
+	}
+}
+
+func proxiesWithWeights(weights ...int) []*Proxy {
+	ps := make([]*Proxy, len(weights))
+	for i, w := range weights {
+		ps[i] = NewProxy("127.0.0.1:53", "dns")
+		ps[i].SetWeight(w)
+	}
+	return ps
+}
+
+func TestWeightedListIsAPermutation(t *testing.T) {
+	p := proxiesWithWeights(1, 5, 10)
+	out := (&weighted{}).List(p)
+
+	if len(out) != len(p) {
+		t.Fatalf("List returned %d proxies, want %d", len(out), len(p))
+	}
+	seen := make(map[*Proxy]bool, len(p))
+	for _, proxy := range out {
+		seen[proxy] = true
+	}
+	for _, proxy := range p {
+		if !seen[proxy] {
+			t.Errorf("weighted.List dropped a proxy: %v", proxy)
+		}
+	}
+}
+
+func TestWeightedListFavorsHigherWeight(t *testing.T) {
+	p := proxiesWithWeights(1, 99)
+	firstCount := 0
+	for i := 0; i < 200; i++ {
+		out := (&weighted{}).List(p)
+		if out[0] == p[1] {
+			firstCount++
+		}
+	}
+	if firstCount < 150 {
+		t.Errorf("heavily-weighted proxy was drawn first %d/200 times, want >= 150", firstCount)
+	}
+}
+
+func TestLatencyListPrefersLowerEWMA(t *testing.T) {
+	fast := NewProxy("127.0.0.1:53", "dns")
+	slow := NewProxy("127.0.0.2:53", "dns")
+	fast.updateLatency(10 * time.Millisecond)
+	slow.updateLatency(500 * time.Millisecond)
+
+	out := (&latency{}).List([]*Proxy{slow, fast})
+	if out[0] != fast {
+		// latency has a small random-exploration chance of not picking the fastest; retry once
+		// before failing to keep the test from being flaky.
+		out = (&latency{}).List([]*Proxy{slow, fast})
+		if out[0] != fast {
+			t.Errorf("latency.List did not prefer the lower-EWMA proxy across two tries")
+		}
+	}
+}
+
+func TestLatencyListUntestedProxySortsLast(t *testing.T) {
+	tested := NewProxy("127.0.0.1:53", "dns")
+	tested.updateLatency(500 * time.Millisecond)
+	untested := NewProxy("127.0.0.2:53", "dns")
+
+	if got := untested.latencyEWMA(); got <= tested.latencyEWMA() {
+		t.Errorf("untested proxy's latencyEWMA = %v, want greater than tested proxy's %v", got, tested.latencyEWMA())
+	}
+}
@@ -0,0 +1,251 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Proxy defines an upstream host.
+type Proxy struct {
+	fails uint32
+
+	addr      string
+	transport string
+
+	// hostname and port are set when addr was configured as a hostname rather than an IP, so
+	// bootstrap can re-resolve it. dialAddr is the address actually dialed; it starts out equal
+	// to addr and is swapped atomically by bootstrap as re-resolution updates it.
+	hostname  string
+	port      string
+	dialAddr  atomic.Value
+	bootstrap *bootstrap
+
+	client *dns.Client
+	doh    *dohClient
+
+	// tls+Expire
+	tlsConfig *tls.Config
+	expire    time.Duration
+
+	health *HealthChecker
+	stopCh chan bool
+
+	// weight is used by the weighted policy; it defaults to 1 and is set from a "#<weight>"
+	// suffix on the proxy's configured to-address.
+	weight int
+
+	// ewmaMu guards ewma, the exponentially-weighted moving average response time (in seconds)
+	// used by the latency policy.
+	ewmaMu sync.Mutex
+	ewma   float64
+}
+
+// NewProxy returns a new proxy.
+func NewProxy(addr, trans string) *Proxy {
+	p := &Proxy{
+		addr:      addr,
+		transport: trans,
+		client:    newClient(trans),
+		expire:    defaultExpire,
+		health:    newHealthChecker(trans),
+		stopCh:    make(chan bool),
+		weight:    1,
+	}
+	p.dialAddr.Store(addr)
+	if host, port, err := net.SplitHostPort(addr); err == nil && net.ParseIP(host) == nil {
+		p.hostname = host
+		p.port = port
+	}
+	if trans == transport.HTTPS {
+		p.doh = newDOHClient(addr)
+	}
+	return p
+}
+
+// dial returns the address currently used to reach the upstream: addr itself, unless bootstrap
+// has re-resolved a hostname to a newer IP.
+func (p *Proxy) dial() string {
+	return p.dialAddr.Load().(string)
+}
+
+// setDialAddr atomically swaps the address used to reach the upstream.
+func (p *Proxy) setDialAddr(addr string) {
+	p.dialAddr.Store(addr)
+	if p.doh != nil {
+		p.doh.setDialAddr(addr)
+	}
+}
+
+// setBootstrap attaches a bootstrap resolver to the proxy, if the proxy was configured with a
+// hostname. The resolver is started by start.
+func (p *Proxy) setBootstrap(b *bootstrap) {
+	if p.hostname == "" {
+		return
+	}
+	p.bootstrap = b
+}
+
+// netForTransport returns the dns.Client network to use for a given upstream transport.
+func netForTransport(trans string) string {
+	if trans == transport.TLS {
+		return "tcp-tls"
+	}
+	return "udp"
+}
+
+// connectNet picks the dns.Client network Connect dials with for a non-DoH proxy. A tls://
+// upstream is always dialed over DoT: force_tcp/prefer_udp only choose the transport for plain
+// dns:// upstreams, and must not downgrade a TLS upstream to an unencrypted connection.
+func connectNet(trans string, opts options) string {
+	switch {
+	case trans == transport.TLS:
+		return "tcp-tls"
+	case opts.forceTCP:
+		return "tcp"
+	case opts.preferUDP:
+		return "udp"
+	}
+	return netForTransport(trans)
+}
+
+func newClient(trans string) *dns.Client {
+	c := new(dns.Client)
+	c.Net = netForTransport(trans)
+	c.ReadTimeout = 2 * time.Second
+	c.WriteTimeout = 2 * time.Second
+	return c
+}
+
+// SetTLSConfig sets the TLS config in the lower p.client, in the healthcheck client too (so
+// healthchecks against a DoT-only upstream don't fail TLS setup), and in the DoH client if any.
+func (p *Proxy) SetTLSConfig(cfg *tls.Config) {
+	p.client.TLSConfig = cfg
+	p.health.client.TLSConfig = cfg
+	p.tlsConfig = cfg
+	if p.doh != nil {
+		p.doh.setTLSConfig(cfg)
+	}
+}
+
+// SetExpire stores the expire duration on the proxy. Connect does not read it: plain DNS and DoT
+// connections are not cached, and idle DoH connections expire after defaultExpire.
+func (p *Proxy) SetExpire(expire time.Duration) { p.expire = expire }
+
+// SetWeight sets the weight used by the weighted policy.
+func (p *Proxy) SetWeight(weight int) { p.weight = weight }
+
+// latencyEWMAAlpha weights how quickly the latency EWMA reacts to a new sample.
+const latencyEWMAAlpha = 0.3
+
+// latencyEWMA returns the current EWMA response time, in seconds, used by the latency policy.
+// A proxy that hasn't answered yet sorts last, so it isn't preferred over already-measured ones.
+func (p *Proxy) latencyEWMA() float64 {
+	p.ewmaMu.Lock()
+	defer p.ewmaMu.Unlock()
+	if p.ewma == 0 {
+		return math.MaxFloat64
+	}
+	return p.ewma
+}
+
+// updateLatency folds a new response time sample into the EWMA and exports it as a gauge.
+func (p *Proxy) updateLatency(d time.Duration) {
+	secs := d.Seconds()
+	p.ewmaMu.Lock()
+	if p.ewma == 0 {
+		p.ewma = secs
+	} else {
+		p.ewma = latencyEWMAAlpha*secs + (1-latencyEWMAAlpha)*p.ewma
+	}
+	ewma := p.ewma
+	p.ewmaMu.Unlock()
+	LatencyEWMA.WithLabelValues(p.addr).Set(ewma)
+}
+
+// Addr returns the address of the proxy.
+func (p *Proxy) Addr() string { return p.addr }
+
+// Fails returns the number of consecutive failures seen on this proxy.
+func (p *Proxy) Fails() uint32 { return atomic.LoadUint32(&p.fails) }
+
+// Down returns true if this proxy should be considered unhealthy.
+func (p *Proxy) Down(maxfails uint32) bool {
+	if maxfails == 0 {
+		return false
+	}
+	return atomic.LoadUint32(&p.fails) >= maxfails
+}
+
+// start starts the proxy's healthchecking, and its bootstrap resolution loop if configured.
+func (p *Proxy) start(duration time.Duration) {
+	if p.bootstrap != nil {
+		p.bootstrap.start(p)
+	}
+	if duration == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(duration)
+		for {
+			select {
+			case <-p.stopCh:
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				p.healthCheck()
+			}
+		}
+	}()
+}
+
+// stop stops the proxy's healthchecking.
+func (p *Proxy) stop() { close(p.stopCh) }
+
+func (p *Proxy) healthCheck() {
+	err := p.health.Check(p)
+	if err != nil {
+		atomic.AddUint32(&p.fails, 1)
+		if p.bootstrap != nil {
+			p.bootstrap.refresh(p)
+		}
+		return
+	}
+	atomic.StoreUint32(&p.fails, 0)
+}
+
+// Connect sends the request and waits for a response.
+func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options) (*dns.Msg, error) {
+	start := time.Now()
+
+	var ret *dns.Msg
+	var err error
+	switch {
+	case p.doh != nil:
+		ret, err = p.doh.exchange(ctx, state.Req)
+	default:
+		co := *p.client
+		co.Net = connectNet(p.transport, opts)
+		ret, _, err = co.ExchangeContext(ctx, state.Req, p.dial())
+	}
+	rtt := time.Since(start)
+	RequestDuration.WithLabelValues(p.addr).Observe(rtt.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	p.updateLatency(rtt)
+
+	RequestCount.WithLabelValues(p.addr).Add(1)
+	RcodeCount.WithLabelValues(dns.RcodeToString[ret.Rcode], p.addr).Add(1)
+
+	return ret, nil
+}
@@ -0,0 +1,28 @@
+package forward
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+func TestConnectNetTLSWinsOverForceAndPrefer(t *testing.T) {
+	tests := []struct {
+		name  string
+		trans string
+		opts  options
+		want  string
+	}{
+		{"dns, no opts", transport.DNS, options{}, "udp"},
+		{"dns, force_tcp", transport.DNS, options{forceTCP: true}, "tcp"},
+		{"dns, prefer_udp", transport.DNS, options{preferUDP: true}, "udp"},
+		{"tls, no opts", transport.TLS, options{}, "tcp-tls"},
+		{"tls, force_tcp must not downgrade", transport.TLS, options{forceTCP: true}, "tcp-tls"},
+		{"tls, prefer_udp must not downgrade", transport.TLS, options{preferUDP: true}, "tcp-tls"},
+	}
+	for _, tc := range tests {
+		if got := connectNet(tc.trans, tc.opts); got != tc.want {
+			t.Errorf("%s: connectNet(%q, %+v) = %q, want %q", tc.name, tc.trans, tc.opts, got, tc.want)
+		}
+	}
+}
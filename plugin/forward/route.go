@@ -0,0 +1,214 @@
+package forward
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+)
+
+// routeRule maps a query name pattern to a subset of f.proxies, addressed by upstream address.
+type routeRule struct {
+	pattern string
+
+	exact  string         // set for a plain, fully-qualified pattern
+	suffix string         // set for a "*.example.com" wildcard pattern, includes the leading dot
+	regex  *regexp.Regexp // set for a "/regex/" pattern
+
+	to []string // upstream addresses this rule routes to
+}
+
+func newRouteRule(pattern string, to []string) (*routeRule, error) {
+	r := &routeRule{pattern: pattern, to: to}
+	switch {
+	case strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") && len(pattern) > 1:
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("route: invalid regex %q: %s", pattern, err)
+		}
+		r.regex = re
+	case strings.HasPrefix(pattern, "*."):
+		r.suffix = plugin.Host(pattern[1:]).Normalize() // ".example.com."
+	default:
+		r.exact = plugin.Host(pattern).Normalize()
+	}
+	return r, nil
+}
+
+// match reports whether qname (fully qualified, e.g. "www.example.com.") satisfies the rule.
+func (r *routeRule) match(qname string) bool {
+	switch {
+	case r.regex != nil:
+		return r.regex.MatchString(qname)
+	case r.suffix != "":
+		return qname == r.suffix[1:] || strings.HasSuffix(qname, r.suffix)
+	default:
+		return qname == r.exact
+	}
+}
+
+// routeTable is an ordered set of routeRules, combining rules given inline in the Corefile (which
+// never change after setup) with rules loaded from an optional file on disk (which are reloaded
+// on a timer). Inline rules are checked first, then file rules, each in the order configured.
+type routeTable struct {
+	mu     sync.RWMutex
+	inline []*routeRule
+	file   []*routeRule
+
+	// validAddrs is the set of configured upstream addresses a rule's "to" list is allowed to
+	// name. It's fixed once f.proxies is known and used to revalidate the route file on every
+	// reload, so a bad edit can only drop that one rule instead of taking down the server.
+	validAddrs map[string]bool
+
+	path   string
+	mtime  time.Time
+	reload time.Duration
+	stopCh chan bool
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{reload: defaultRouteReload}
+}
+
+// addInline appends a rule parsed from an inline "route" directive.
+func (rt *routeTable) addInline(rule *routeRule) {
+	rt.mu.Lock()
+	rt.inline = append(rt.inline, rule)
+	rt.mu.Unlock()
+}
+
+// setFile replaces the rules loaded from the routing file, leaving inline rules untouched.
+func (rt *routeTable) setFile(rules []*routeRule) {
+	rt.mu.Lock()
+	rt.file = rules
+	rt.mu.Unlock()
+}
+
+// setValidAddrs records the set of addresses a rule's "to" list may legally name.
+func (rt *routeTable) setValidAddrs(addrs map[string]bool) {
+	rt.mu.Lock()
+	rt.validAddrs = addrs
+	rt.mu.Unlock()
+}
+
+// dropInvalid filters out rules naming an address outside rt.validAddrs, logging a warning for
+// each one dropped rather than letting a bad line in a hot-reloaded route file either silently
+// mis-route queries or, if none of the rule's "to" addresses is configured, leave its queries
+// with no upstream at all, so they fail with ErrNoHealthy.
+func (rt *routeTable) dropInvalid(rules []*routeRule) []*routeRule {
+	rt.mu.RLock()
+	valid := rt.validAddrs
+	rt.mu.RUnlock()
+
+	out := make([]*routeRule, 0, len(rules))
+	for _, r := range rules {
+		ok := true
+		for _, a := range r.to {
+			if !valid[a] {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			clog.Warningf("route: skipping rule %q: %v is not one of the configured upstreams", r.pattern, r.to)
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// route returns the upstream addresses for the first rule matching qname, and whether any rule matched.
+func (rt *routeTable) route(qname string) ([]string, bool) {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	for _, rules := range [][]*routeRule{rt.inline, rt.file} {
+		for _, r := range rules {
+			if r.match(qname) {
+				RouteMatchCount.WithLabelValues(r.pattern).Add(1)
+				return r.to, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// loadFile parses the routing rule file. Each non-comment, non-blank line has the form:
+//
+//	<pattern> <to>...
+func (rt *routeTable) loadFile(file string) ([]*routeRule, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []*routeRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		rule, err := newRouteRule(fields[0], fields[1:])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// startReload polls the routing file's mtime and reloads the rule table when it changes.
+func (rt *routeTable) startReload() {
+	if rt.path == "" {
+		return
+	}
+	rt.stopCh = make(chan bool)
+	go func() {
+		ticker := time.NewTicker(rt.reload)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rt.stopCh:
+				return
+			case <-ticker.C:
+				rt.maybeReload()
+			}
+		}
+	}()
+}
+
+func (rt *routeTable) maybeReload() {
+	info, err := os.Stat(rt.path)
+	if err != nil {
+		return
+	}
+	if !info.ModTime().After(rt.mtime) {
+		return
+	}
+	rules, err := rt.loadFile(rt.path)
+	if err != nil {
+		return
+	}
+	rt.mtime = info.ModTime()
+	rt.setFile(rt.dropInvalid(rules))
+}
+
+func (rt *routeTable) stop() {
+	if rt.stopCh != nil {
+		close(rt.stopCh)
+	}
+}
+
+const defaultRouteReload = 30 * time.Second
@@ -0,0 +1,143 @@
+package forward
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRouteRuleMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		qname   string
+		want    bool
+	}{
+		{"www.example.org.", "www.example.org.", true},
+		{"www.example.org.", "other.example.org.", false},
+		{"*.example.org", "www.example.org.", true},
+		{"*.example.org", "example.org.", true},
+		{"*.example.org", "example.com.", false},
+		{"/^a.*\\.org\\.$/", "anything.org.", true},
+		{"/^a.*\\.org\\.$/", "bee.org.", false},
+	}
+	for _, tc := range tests {
+		r, err := newRouteRule(tc.pattern, []string{"127.0.0.1:53"})
+		if err != nil {
+			t.Fatalf("newRouteRule(%q): %s", tc.pattern, err)
+		}
+		if got := r.match(tc.qname); got != tc.want {
+			t.Errorf("rule %q matching %q = %v, want %v", tc.pattern, tc.qname, got, tc.want)
+		}
+	}
+}
+
+func TestRouteTableInlineAndFileBothApply(t *testing.T) {
+	rt := newRouteTable()
+
+	inline, err := newRouteRule("inline.example.org.", []string{"127.0.0.1:53"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.addInline(inline)
+
+	fromFile, err := newRouteRule("file.example.org.", []string{"127.0.0.2:53"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.setFile([]*routeRule{fromFile})
+
+	if addrs, ok := rt.route("inline.example.org."); !ok || addrs[0] != "127.0.0.1:53" {
+		t.Errorf("route(inline.example.org.) = %v, %v, want [127.0.0.1:53], true", addrs, ok)
+	}
+	if addrs, ok := rt.route("file.example.org."); !ok || addrs[0] != "127.0.0.2:53" {
+		t.Errorf("route(file.example.org.) = %v, %v, want [127.0.0.2:53], true", addrs, ok)
+	}
+
+	// A reload of the file rules must not disturb the inline rule.
+	rt.setFile(nil)
+	if _, ok := rt.route("inline.example.org."); !ok {
+		t.Error("inline rule was lost after a file-rule reload")
+	}
+	if _, ok := rt.route("file.example.org."); ok {
+		t.Error("stale file rule matched after the file rules were reloaded away")
+	}
+}
+
+func TestValidateRouteAddrsRejectsUnknownUpstream(t *testing.T) {
+	f := New()
+	f.proxies = []*Proxy{NewProxy("127.0.0.1:53", "dns")}
+
+	if err := validateRouteAddrs(f, []string{"127.0.0.1:53"}); err != nil {
+		t.Errorf("validateRouteAddrs with a known address: %s", err)
+	}
+	if err := validateRouteAddrs(f, []string{"10.0.0.1:53"}); err == nil {
+		t.Error("validateRouteAddrs should reject an address that isn't a configured upstream")
+	}
+}
+
+// TestDropInvalidSkipsRulesNamingUnknownUpstreams guards against a route_file hot-reload that
+// picks up a rule naming an upstream that no longer exists: dropInvalid must skip just that rule
+// rather than handing back a table whose rule filterProxies maps to an empty proxy list (the
+// round-robin policy used to divide by zero on an empty proxy list).
+func TestDropInvalidSkipsRulesNamingUnknownUpstreams(t *testing.T) {
+	rt := newRouteTable()
+	rt.setValidAddrs(map[string]bool{"127.0.0.1:53": true})
+
+	good, err := newRouteRule("good.example.org.", []string{"127.0.0.1:53"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad, err := newRouteRule("bad.example.org.", []string{"10.0.0.1:53"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := rt.dropInvalid([]*routeRule{good, bad})
+	if len(out) != 1 || out[0] != good {
+		t.Fatalf("dropInvalid = %v, want only the rule naming a known upstream", out)
+	}
+}
+
+// TestMaybeReloadDropsRuleWithUnknownUpstream exercises the reload path end-to-end: a route file
+// edited to reference an address outside the configured upstreams must not replace a previously
+// good rule with one that names no configured upstream.
+func TestMaybeReloadDropsRuleWithUnknownUpstream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "route_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := dir + "/routes"
+	if err := ioutil.WriteFile(file, []byte("good.example.org. 127.0.0.1:53\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rt := newRouteTable()
+	rt.setValidAddrs(map[string]bool{"127.0.0.1:53": true})
+	rt.path = file
+
+	rules, err := rt.loadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.mtime = info.ModTime()
+	rt.setFile(rt.dropInvalid(rules))
+
+	if err := ioutil.WriteFile(file, []byte("good.example.org. 10.0.0.1:53\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, time.Now().Add(time.Second), time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	rt.maybeReload()
+
+	if _, ok := rt.route("good.example.org."); ok {
+		t.Error("maybeReload kept a rule naming an address outside the configured upstreams")
+	}
+}
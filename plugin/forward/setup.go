@@ -12,6 +12,7 @@ import (
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/dnstap"
 	"github.com/coredns/coredns/plugin/metrics"
 	"github.com/coredns/coredns/plugin/pkg/parse"
 	pkgtls "github.com/coredns/coredns/plugin/pkg/tls"
@@ -40,7 +41,12 @@ func setup(c *caddy.Controller) error {
 		})
 
 		c.OnStartup(func() error {
-			metrics.MustRegister(c, RequestCount, RcodeCount, RequestDuration, HealthcheckFailureCount, SocketGauge, MaxConcurrentRejectCount)
+			metrics.MustRegister(c, RequestCount, RcodeCount, RequestDuration, HealthcheckFailureCount, MaxConcurrentRejectCount, RouteMatchCount, LatencyEWMA, CacheHitCount, CacheMissCount)
+			if f.dnstapEnabled {
+				if tapper, ok := dnsserver.GetConfig(c).Handler("dnstap").(dnstap.Dnstapper); ok {
+					f.tapPlugin = tapper
+				}
+			}
 			return f.OnStartup()
 		})
 
@@ -57,6 +63,9 @@ func (f *Forward) OnStartup() (err error) {
 	for _, p := range f.proxies {
 		p.start(f.hcInterval)
 	}
+	if f.route != nil {
+		f.route.startReload()
+	}
 	return nil
 }
 
@@ -65,6 +74,9 @@ func (f *Forward) OnShutdown() error {
 	for _, p := range f.proxies {
 		p.stop()
 	}
+	if f.route != nil {
+		f.route.stop()
+	}
 	return nil
 }
 
@@ -96,13 +108,29 @@ func parseStanza(c *caddy.Controller) (*Forward, error) {
 		return f, c.ArgErr()
 	}
 
+	// A to-address may carry a "#<weight>" suffix for the weighted policy, e.g. "1.1.1.1:53#10".
+	// Strip it before resolving the host list; weights only apply when to isn't a @file list,
+	// since HostPortOrFile-expanded entries don't correspond 1:1 with the original args.
+	weights := make([]int, len(to))
+	for i, t := range to {
+		weights[i] = 1
+		if idx := strings.LastIndex(t, "#"); idx >= 0 {
+			w, err := strconv.Atoi(t[idx+1:])
+			if err != nil || w <= 0 {
+				return f, fmt.Errorf("invalid weight in '%s'", t)
+			}
+			weights[i] = w
+			to[i] = t[:idx]
+		}
+	}
+
 	toHosts, err := parse.HostPortOrFile(to...)
 	if err != nil {
 		return f, err
 	}
 
 	transports := make([]string, len(toHosts))
-	allowedTrans := map[string]bool{"dns": true, "tls": true}
+	allowedTrans := map[string]bool{"dns": true, "tls": true, "https": true}
 	for i, host := range toHosts {
 		trans, h := parse.Transport(host)
 
@@ -110,6 +138,9 @@ func parseStanza(c *caddy.Controller) (*Forward, error) {
 			return f, fmt.Errorf("'%s' is not supported as a destination protocol in forward: %s", trans, host)
 		}
 		p := NewProxy(h, trans)
+		if len(toHosts) == len(to) {
+			p.SetWeight(weights[i])
+		}
 		f.proxies = append(f.proxies, p)
 		transports[i] = trans
 	}
@@ -125,16 +156,51 @@ func parseStanza(c *caddy.Controller) (*Forward, error) {
 	}
 	for i := range f.proxies {
 		// Only set this for proxies that need it.
-		if transports[i] == transport.TLS {
+		if transports[i] == transport.TLS || transports[i] == transport.HTTPS {
 			f.proxies[i].SetTLSConfig(f.tlsConfig)
 		}
 		f.proxies[i].SetExpire(f.expire)
 		f.proxies[i].health.SetRecursionDesired(f.opts.hcRecursionDesired)
 	}
+	if len(f.bootstrapResolvers) > 0 {
+		bs := newBootstrap(f.bootstrapResolvers, f.bootstrapInterval)
+		for _, p := range f.proxies {
+			p.setBootstrap(bs)
+		}
+	}
 
 	return f, nil
 }
 
+// routeAddrSet returns the set of addresses a route rule's "to" list may legally name.
+func routeAddrSet(proxies []*Proxy) map[string]bool {
+	known := make(map[string]bool, len(proxies))
+	for _, p := range proxies {
+		known[p.addr] = true
+	}
+	return known
+}
+
+// validateRouteAddrs rejects a route rule whose "to" list names an address that isn't one of
+// f.proxies, instead of letting it silently fall back to routing to every upstream at query time.
+func validateRouteAddrs(f *Forward, addrs []string) error {
+	known := routeAddrSet(f.proxies)
+	for _, a := range addrs {
+		if !known[a] {
+			return fmt.Errorf("route: %q is not one of the configured upstreams", a)
+		}
+	}
+	return nil
+}
+
+// newRouteTableFor creates a route table that knows f's configured upstreams, so a later
+// hot-reload of route_file can revalidate rules against them.
+func newRouteTableFor(f *Forward) *routeTable {
+	rt := newRouteTable()
+	rt.setValidAddrs(routeAddrSet(f.proxies))
+	return rt
+}
+
 func parseBlock(c *caddy.Controller, f *Forward) error {
 	switch c.Val() {
 	case "except":
@@ -261,6 +327,10 @@ func parseBlock(c *caddy.Controller, f *Forward) error {
 			f.p = &roundRobin{}
 		case "sequential":
 			f.p = &sequential{}
+		case "weighted":
+			f.p = &weighted{}
+		case "latency":
+			f.p = &latency{}
 		default:
 			return c.Errf("unknown policy '%s'", x)
 		}
@@ -277,6 +347,123 @@ func parseBlock(c *caddy.Controller, f *Forward) error {
 		}
 		f.ErrLimitExceeded = errors.New("concurrent queries exceeded maximum " + c.Val())
 		f.maxConcurrent = int64(n)
+	case "route":
+		args := c.RemainingArgs()
+		if len(args) < 2 {
+			return c.ArgErr()
+		}
+		if err := validateRouteAddrs(f, args[1:]); err != nil {
+			return err
+		}
+		rule, err := newRouteRule(args[0], args[1:])
+		if err != nil {
+			return err
+		}
+		if f.route == nil {
+			f.route = newRouteTableFor(f)
+		}
+		f.route.addInline(rule)
+	case "route_file":
+		args := c.RemainingArgs()
+		if len(args) == 0 || len(args) > 2 {
+			return c.ArgErr()
+		}
+		if f.route == nil {
+			f.route = newRouteTableFor(f)
+		}
+		f.route.path = args[0]
+		if len(args) == 2 {
+			dur, err := time.ParseDuration(args[1])
+			if err != nil {
+				return err
+			}
+			f.route.reload = dur
+		}
+		rules, err := f.route.loadFile(f.route.path)
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			if err := validateRouteAddrs(f, rule.to); err != nil {
+				return err
+			}
+		}
+		info, err := os.Stat(f.route.path)
+		if err != nil {
+			return err
+		}
+		f.route.mtime = info.ModTime()
+		f.route.setFile(rules)
+	case "cache":
+		f.cache = newForwardCache()
+		args := c.RemainingArgs()
+		switch len(args) {
+		case 0:
+		case 1:
+			n, err := parseCacheSize(args[0])
+			if err != nil {
+				return err
+			}
+			f.cache.capacity = n
+		default:
+			return c.ArgErr()
+		}
+		for c.NextBlock() {
+			switch c.Val() {
+			case "min_ttl":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				dur, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return err
+				}
+				f.cache.minTTL = dur
+			case "max_ttl":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				dur, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return err
+				}
+				f.cache.maxTTL = dur
+			case "negative_ttl":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				dur, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return err
+				}
+				f.cache.negTTL = dur
+			default:
+				return c.Errf("unknown cache property '%s'", c.Val())
+			}
+		}
+	case "dnstap":
+		if c.NextArg() {
+			return c.ArgErr()
+		}
+		f.dnstapEnabled = true
+	case "bootstrap":
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return c.ArgErr()
+		}
+		f.bootstrapResolvers = args
+	case "bootstrap_interval":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		dur, err := time.ParseDuration(c.Val())
+		if err != nil {
+			return err
+		}
+		if dur < 0 {
+			return fmt.Errorf("bootstrap_interval can't be negative: %s", dur)
+		}
+		f.bootstrapInterval = dur
 	case "retry_failed":
 		if !c.NextArg() {
 			return c.ArgErr()
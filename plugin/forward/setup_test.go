@@ -0,0 +1,112 @@
+package forward
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestParseForwardOK(t *testing.T) {
+	c := caddy.NewTestController("dns", `forward . 127.0.0.1:53 127.0.0.2:53#5 {
+		max_fails 3
+		policy weighted
+	}`)
+	fs, err := parseForward(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fs) != 1 {
+		t.Fatalf("parseForward returned %d stanzas, want 1", len(fs))
+	}
+	f := fs[0]
+	if f.Len() != 2 {
+		t.Fatalf("f.Len() = %d, want 2", f.Len())
+	}
+	if f.maxfails != 3 {
+		t.Errorf("f.maxfails = %d, want 3", f.maxfails)
+	}
+	if _, ok := f.p.(*weighted); !ok {
+		t.Errorf("f.p = %T, want *weighted", f.p)
+	}
+	if w := f.proxies[1].weight; w != 5 {
+		t.Errorf("second proxy's weight = %d, want 5 (from the #5 suffix)", w)
+	}
+}
+
+func TestParseWeightSuffixError(t *testing.T) {
+	c := caddy.NewTestController("dns", `forward . 127.0.0.1:53#notanumber`)
+	if _, err := parseForward(c); err == nil {
+		t.Error("expected an error for a non-numeric weight suffix")
+	}
+}
+
+func TestParseCacheArgCount(t *testing.T) {
+	c := caddy.NewTestController("dns", `forward . 127.0.0.1:53 {
+		cache 100 200
+	}`)
+	if _, err := parseForward(c); err == nil {
+		t.Error("expected an error for too many cache arguments")
+	}
+}
+
+func TestParseCacheUnknownProperty(t *testing.T) {
+	c := caddy.NewTestController("dns", `forward . 127.0.0.1:53 {
+		cache {
+			bogus 1m
+		}
+	}`)
+	if _, err := parseForward(c); err == nil {
+		t.Error("expected an error for an unknown cache property")
+	}
+}
+
+func TestParseBootstrapRequiresArgs(t *testing.T) {
+	c := caddy.NewTestController("dns", `forward . dns.example.org:53 {
+		bootstrap
+	}`)
+	if _, err := parseForward(c); err == nil {
+		t.Error("expected an error for bootstrap with no resolvers")
+	}
+}
+
+func TestParseBootstrapIntervalNegative(t *testing.T) {
+	c := caddy.NewTestController("dns", `forward . dns.example.org:53 {
+		bootstrap 1.1.1.1
+		bootstrap_interval -1s
+	}`)
+	if _, err := parseForward(c); err == nil {
+		t.Error("expected an error for a negative bootstrap_interval")
+	}
+}
+
+func TestParseRouteArgCountError(t *testing.T) {
+	c := caddy.NewTestController("dns", `forward . 127.0.0.1:53 {
+		route example.org.
+	}`)
+	if _, err := parseForward(c); err == nil {
+		t.Error("expected an error for a route directive with no 'to' addresses")
+	}
+}
+
+func TestParseRouteUnknownUpstream(t *testing.T) {
+	input := `forward . 127.0.0.1:53 {
+		route example.org. 10.0.0.1:53
+	}`
+	_, err := parseForward(caddy.NewTestController("dns", input))
+	if err == nil {
+		t.Fatal("expected an error for a route directive naming an unconfigured upstream")
+	}
+	if !strings.Contains(err.Error(), "10.0.0.1:53") {
+		t.Errorf("error %q does not mention the offending address", err)
+	}
+}
+
+func TestParseRouteFileArgCountError(t *testing.T) {
+	c := caddy.NewTestController("dns", `forward . 127.0.0.1:53 {
+		route_file a b c
+	}`)
+	if _, err := parseForward(c); err == nil {
+		t.Error("expected an error for route_file with more than two arguments")
+	}
+}
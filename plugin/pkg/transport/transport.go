@@ -0,0 +1,13 @@
+// Package transport holds the name of the transports that can be used to
+// contact a DNS server.
+package transport
+
+// These transports are supported.
+const (
+	// DNS is plain DNS over UDP/TCP.
+	DNS = "dns"
+	// TLS is DNS over TLS (DoT).
+	TLS = "tls"
+	// HTTPS is DNS over HTTPS (DoH), RFC 8484.
+	HTTPS = "https"
+)